@@ -0,0 +1,266 @@
+package graph
+
+// Dominator computation treats every GC root as a child of a synthetic
+// super-root, so a single dominator tree over "root + objects" gives the
+// retained-size answer for the whole heap: an object's retained set is
+// exactly the objects it dominates.
+const superRoot = -1
+
+// dominators holds the Lengauer-Tarjan working state for one run. It
+// operates on "node ids" where superRoot is the synthetic root and every
+// other node id is an object ordinal.
+type dominators struct {
+	g *Graph
+
+	dfnum  map[int32]int // node -> DFS number, absent if unvisited
+	vertex []int32       // DFS number -> node
+	parent []int32       // DFS number -> parent's DFS number
+
+	ancestor []int32
+	label    []int32
+	semi     []int
+	idomDFN  []int32
+	bucket   [][]int32
+}
+
+func (g *Graph) successors(n int32) []int32 {
+	if n == superRoot {
+		return g.roots
+	}
+	return g.out[n]
+}
+
+// predecessors returns every node with an edge into n, including the
+// synthetic super-root when n is a GC root: successors(superRoot)
+// includes every root, so the semi-dominator pass must see the reverse
+// edge too, or a root that is also pointed to by another object would be
+// dominated by that object instead of by the super-root.
+func (g *Graph) predecessors(n int32) []int32 {
+	if g.isRoot[n] {
+		return append([]int32{superRoot}, g.in[n]...)
+	}
+	return g.in[n]
+}
+
+// computeDominators runs Lengauer-Tarjan over the graph rooted at the
+// synthetic super-root and returns, for every object ordinal, the
+// ordinal of its immediate dominator (or superRoot if only the root
+// dominates it, or -2 if the object is unreachable from any GC root).
+func (g *Graph) computeDominators() []int32 {
+	n := len(g.objects)
+	d := &dominators{
+		g:        g,
+		dfnum:    make(map[int32]int),
+		ancestor: make([]int32, n+1),
+		label:    make([]int32, n+1),
+		semi:     make([]int, n+1),
+		idomDFN:  make([]int32, n+1),
+		bucket:   make([][]int32, n+1),
+	}
+	for i := range d.ancestor {
+		d.ancestor[i] = -1
+	}
+
+	d.dfs(superRoot)
+	nv := len(d.vertex)
+	for i := range d.label[:nv] {
+		d.label[i] = int32(i)
+		d.semi[i] = i
+	}
+
+	idom := make([]int32, n)
+	for i := range idom {
+		idom[i] = -2 // unreachable until proven otherwise
+	}
+
+	// Process DFS numbers from last to first (excluding the root, dfnum 0).
+	for i := nv - 1; i >= 1; i-- {
+		w := d.vertex[i]
+		// Step 1: compute semi-dominators.
+		for _, v := range g.predecessors(w) {
+			pv, ok := d.dfnum[v]
+			if !ok {
+				continue
+			}
+			var u int32
+			if pv <= i {
+				u = int32(pv)
+			} else {
+				u = d.eval(int32(pv))
+			}
+			if d.semi[u] < d.semi[i] {
+				d.semi[i] = d.semi[u]
+			}
+		}
+		d.bucket[d.semi[i]] = append(d.bucket[d.semi[i]], int32(i))
+		d.link(d.parent[i], int32(i))
+
+		// Step 2: implicitly define idom for nodes in parent's bucket.
+		pi := d.parent[i]
+		for _, v := range d.bucket[pi] {
+			u := d.eval(v)
+			if d.semi[u] < d.semi[v] {
+				d.idomDFN[v] = u
+			} else {
+				d.idomDFN[v] = pi
+			}
+		}
+		d.bucket[pi] = nil
+	}
+
+	// Step 3: finalize idom for nodes whose idom differs from their semi.
+	for i := 1; i < nv; i++ {
+		if d.idomDFN[i] != int32(d.semi[i]) {
+			d.idomDFN[i] = d.idomDFN[d.idomDFN[i]]
+		}
+	}
+
+	for i := 1; i < nv; i++ {
+		node := d.vertex[i]
+		if node == superRoot {
+			continue
+		}
+		idom[node] = d.vertex[d.idomDFN[i]]
+	}
+	return idom
+}
+
+// dfs numbers every node reachable from root in DFS (pre-)order,
+// recording each node's parent DFS number.
+func (d *dominators) dfs(root int32) {
+	type frame struct {
+		node int32
+		next int
+		succ []int32
+	}
+	stack := []frame{{root, 0, d.g.successors(root)}}
+	d.dfnum[root] = 0
+	d.vertex = append(d.vertex, root)
+	d.parent = append(d.parent, -1)
+
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next >= len(top.succ) {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		next := top.succ[top.next]
+		top.next++
+		if _, seen := d.dfnum[next]; seen {
+			continue
+		}
+		d.dfnum[next] = len(d.vertex)
+		d.parent = append(d.parent, int32(d.dfnum[top.node]))
+		d.vertex = append(d.vertex, next)
+		stack = append(stack, frame{next, 0, d.g.successors(next)})
+	}
+}
+
+func (d *dominators) link(parentDFN, nodeDFN int32) {
+	d.ancestor[nodeDFN] = parentDFN
+}
+
+// eval follows the ancestor chain from v, applying path compression, and
+// returns the DFS number (by proxy of its label) of the node with the
+// smallest semidominator number on the path from v to the root of its
+// link-eval tree.
+func (d *dominators) eval(v int32) int32 {
+	if d.ancestor[v] == -1 {
+		return v
+	}
+	d.compress(v)
+	return d.label[v]
+}
+
+func (d *dominators) compress(v int32) {
+	// Iteratively compress the ancestor chain, matching the recursive
+	// textbook definition without risking stack growth on deep chains.
+	var path []int32
+	a := v
+	for d.ancestor[a] != -1 && d.ancestor[d.ancestor[a]] != -1 {
+		path = append(path, a)
+		a = d.ancestor[a]
+	}
+	for i := len(path) - 1; i >= 0; i-- {
+		n := path[i]
+		anc := d.ancestor[n]
+		if d.semi[d.label[anc]] < d.semi[d.label[n]] {
+			d.label[n] = d.label[anc]
+		}
+		d.ancestor[n] = d.ancestor[anc]
+	}
+}
+
+// Dominators returns, for every object ordinal, the ordinal of its
+// immediate dominator in the GC-root reachability graph. An object that
+// is only dominated by the synthetic super-root (i.e. it is a root
+// itself, or no single object lies on every path to it from the roots)
+// reports -1; an object unreachable from any root reports -2.
+func (g *Graph) Dominators() []int32 {
+	if g.idom == nil {
+		g.idom = g.computeDominators()
+	}
+	return g.idom
+}
+
+// RetainedSize returns, for every object ordinal, the sum of its own
+// shallow size and the shallow sizes of every object it dominates: the
+// memory that would become unreachable if this object were freed.
+func (g *Graph) RetainedSize() []uint64 {
+	if g.retained != nil {
+		return g.retained
+	}
+	idom := g.Dominators()
+	n := len(g.objects)
+
+	children := make([][]int32, n)
+	var order []int32
+	for ord := int32(0); ord < int32(n); ord++ {
+		switch p := idom[ord]; p {
+		case -2:
+			// unreachable: not part of any dominator tree
+		case -1:
+			order = append(order, ord) // direct child of the super-root
+		default:
+			children[p] = append(children[p], ord)
+		}
+	}
+
+	retained := make([]uint64, n)
+	// Post-order walk over an explicit stack, not recursion: a long
+	// dominated chain (e.g. a linked list) would otherwise recurse one
+	// frame per object, which is exactly what the multi-GB heaps
+	// chunk0-5 targets would blow the goroutine stack on.
+	type frame struct {
+		ord  int32
+		next int
+	}
+	for _, root := range order {
+		stack := []frame{{root, 0}}
+		for len(stack) > 0 {
+			top := &stack[len(stack)-1]
+			if top.next < len(children[top.ord]) {
+				c := children[top.ord][top.next]
+				top.next++
+				stack = append(stack, frame{c, 0})
+				continue
+			}
+			total := g.shallowSize(top.ord)
+			for _, c := range children[top.ord] {
+				total += retained[c]
+			}
+			retained[top.ord] = total
+			stack = stack[:len(stack)-1]
+		}
+	}
+	g.retained = retained
+	return retained
+}
+
+func (g *Graph) shallowSize(ord int32) uint64 {
+	o := g.objects[ord]
+	if o.Type != nil {
+		return o.Type.Size
+	}
+	return o.Size
+}
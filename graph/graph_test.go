@@ -0,0 +1,213 @@
+package graph_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dullgiulio/hdump/graph"
+	"github.com/dullgiulio/hdump/writer"
+)
+
+// buildDump writes a small synthetic heap:
+//
+//	root --otherroot--> A --ptr--> B --ptr--> D
+//	                     \--ptr--> C --ptr--> D
+//
+// D is reachable via both B and C, so neither of them dominates it
+// alone; every path to B, C and D passes through A, so A dominates all
+// three.
+func buildDump(t *testing.T) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := writer.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	const (
+		addrA = 0xa0
+		addrB = 0xb0
+		addrC = 0xc0
+		addrD = 0xd0
+	)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	must(w.WriteParams(false, 8, 0, 0, "amd64", "", 1))
+	must(w.WriteObject(addrA, []byte("A"), []writer.Field{
+		{Kind: writer.FieldKindPtr, Ptr: addrB},
+		{Kind: writer.FieldKindPtr, Ptr: addrC},
+	}))
+	must(w.WriteObject(addrB, []byte("BB"), []writer.Field{
+		{Kind: writer.FieldKindPtr, Ptr: addrD},
+	}))
+	must(w.WriteObject(addrC, []byte("CCC"), []writer.Field{
+		{Kind: writer.FieldKindPtr, Ptr: addrD},
+	}))
+	must(w.WriteObject(addrD, []byte("DDDD"), nil))
+	must(w.WriteOtherRoot("root", addrA))
+	must(w.Close())
+	return buf.Bytes()
+}
+
+func TestRetainedSizeAndDominators(t *testing.T) {
+	g, err := graph.Build(bytes.NewReader(buildDump(t)))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	if g.Len() != 4 {
+		t.Fatalf("expected 4 objects, got %d", g.Len())
+	}
+
+	ordA, _ := g.Find(0xa0)
+	ordB, _ := g.Find(0xb0)
+	ordC, _ := g.Find(0xc0)
+	ordD, _ := g.Find(0xd0)
+
+	idom := g.Dominators()
+	if idom[ordB] != ordA {
+		t.Errorf("B's dominator = %d, want A (%d)", idom[ordB], ordA)
+	}
+	if idom[ordC] != ordA {
+		t.Errorf("C's dominator = %d, want A (%d)", idom[ordC], ordA)
+	}
+	if idom[ordD] == ordB || idom[ordD] == ordC {
+		t.Errorf("D is reachable via both B and C, so neither should dominate it alone; got %d", idom[ordD])
+	}
+
+	retained := g.RetainedSize()
+	wantA := uint64(len("A") + len("BB") + len("CCC") + len("DDDD"))
+	if retained[ordA] != wantA {
+		t.Errorf("retained(A) = %d, want %d", retained[ordA], wantA)
+	}
+	if retained[ordB] != uint64(len("BB")) {
+		t.Errorf("retained(B) = %d, want %d (D is shared, not dominated by B)", retained[ordB], len("BB"))
+	}
+}
+
+// TestRetainedSizeRootAlsoReferenced covers a root that is also pointed
+// to by another reachable object: root --otherroot--> A --ptr--> B, and
+// a data-segment root pointing directly at B too. B must be dominated
+// by the super-root (reported as -1), not by A, since it is reachable
+// without passing through A.
+func TestRetainedSizeRootAlsoReferenced(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := writer.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	const (
+		addrA = 0xa0
+		addrB = 0xb0
+	)
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	must(w.WriteParams(false, 8, 0, 0, "amd64", "", 1))
+	must(w.WriteObject(addrA, []byte("A"), []writer.Field{
+		{Kind: writer.FieldKindPtr, Ptr: addrB},
+	}))
+	must(w.WriteObject(addrB, []byte("BB"), nil))
+	must(w.WriteOtherRoot("root", addrA))
+	must(w.WriteData(0, nil, []writer.Field{{Kind: writer.FieldKindPtr, Ptr: addrB}}))
+	must(w.Close())
+
+	g, err := graph.Build(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	ordA, _ := g.Find(addrA)
+	ordB, _ := g.Find(addrB)
+
+	idom := g.Dominators()
+	if idom[ordB] != -1 {
+		t.Errorf("B's dominator = %d, want -1 (only the super-root dominates a directly-rooted object)", idom[ordB])
+	}
+
+	retained := g.RetainedSize()
+	if retained[ordA] != uint64(len("A")) {
+		t.Errorf("retained(A) = %d, want %d (B is also a root, not dominated by A)", retained[ordA], len("A"))
+	}
+	if retained[ordB] != uint64(len("BB")) {
+		t.Errorf("retained(B) = %d, want %d", retained[ordB], len("BB"))
+	}
+}
+
+func TestShortestPathToRoot(t *testing.T) {
+	g, err := graph.Build(bytes.NewReader(buildDump(t)))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	path, ok := g.ShortestPathToRoot(0xd0)
+	if !ok {
+		t.Fatalf("expected a path from D to a root")
+	}
+	if len(path) != 3 {
+		t.Fatalf("expected a 3-hop path (D, {B or C}, A), got %d: %+v", len(path), path)
+	}
+	if !path[len(path)-1].IsRoot {
+		t.Errorf("last step should be a root: %+v", path[len(path)-1])
+	}
+	ordA, _ := g.Find(0xa0)
+	if path[len(path)-1].Ordinal != ordA {
+		t.Errorf("root step = %d, want A (%d)", path[len(path)-1].Ordinal, ordA)
+	}
+}
+
+func TestGroupByType(t *testing.T) {
+	g, err := graph.Build(bytes.NewReader(buildDump(t)))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	stats := g.GroupByType()
+	if len(stats) != 1 || stats[0].Name != "<unknown>" || stats[0].Count != 4 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+// TestRetainedSizeDeepChain builds a long singly-linked chain, rooted at
+// one end, and checks that RetainedSize neither blows the goroutine
+// stack nor miscounts: this is the shape a long-lived linked list or
+// queue takes in a real multi-GB heap dump.
+func TestRetainedSizeDeepChain(t *testing.T) {
+	const n = 200000
+	var buf bytes.Buffer
+	w, err := writer.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteParams(false, 8, 0, 0, "amd64", "", 1); err != nil {
+		t.Fatalf("WriteParams: %v", err)
+	}
+	addr := func(i int) uint64 { return uint64(i + 1) }
+	for i := 0; i < n; i++ {
+		var fields []writer.Field
+		if i+1 < n {
+			fields = []writer.Field{{Kind: writer.FieldKindPtr, Ptr: addr(i + 1)}}
+		}
+		if err := w.WriteObject(addr(i), []byte{byte(i)}, fields); err != nil {
+			t.Fatalf("WriteObject(%d): %v", i, err)
+		}
+	}
+	if err := w.WriteOtherRoot("root", addr(0)); err != nil {
+		t.Fatalf("WriteOtherRoot: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	g, err := graph.Build(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Build: %v", err)
+	}
+	retained := g.RetainedSize()
+	ordHead, _ := g.Find(addr(0))
+	if retained[ordHead] != uint64(n) {
+		t.Fatalf("retained(head) = %d, want %d", retained[ordHead], n)
+	}
+}
@@ -0,0 +1,69 @@
+package graph
+
+// PathStep is one hop on a retaining path: the object at this step, and
+// whether it is itself a GC root (in which case it ends the path).
+type PathStep struct {
+	Ordinal int32
+	IsRoot  bool
+}
+
+// ShortestPathToRoot finds the shortest chain of incoming references
+// that keeps addr alive, by breadth-first search over the incoming-edge
+// index starting at addr and walking predecessors until a GC root is
+// reached. The returned path runs from addr to the root; ok is false if
+// addr is unknown or unreachable from any root.
+func (g *Graph) ShortestPathToRoot(addr uint64) (path []PathStep, ok bool) {
+	start, found := g.addrIndex[addr]
+	if !found {
+		return nil, false
+	}
+
+	visited := make(map[int32]bool)
+	prev := make(map[int32]int32)
+	queue := []int32{start}
+	visited[start] = true
+
+	var target int32 = -1
+	if g.isRoot[start] {
+		target = start
+	} else {
+		for len(queue) > 0 {
+			n := queue[0]
+			queue = queue[1:]
+			for _, p := range g.predecessors(n) {
+				if visited[p] {
+					continue
+				}
+				visited[p] = true
+				prev[p] = n
+				if g.isRoot[p] {
+					target = p
+					queue = nil
+					break
+				}
+				queue = append(queue, p)
+			}
+		}
+	}
+	if target == -1 {
+		return nil, false
+	}
+
+	// prev chains from each discovered node back toward start, so walking
+	// it from target reconstructs root->...->start; reverse for
+	// start->...->root.
+	var reversed []int32
+	for n := target; ; {
+		reversed = append(reversed, n)
+		if n == start {
+			break
+		}
+		n = prev[n]
+	}
+	path = make([]PathStep, len(reversed))
+	for i, ord := range reversed {
+		j := len(reversed) - 1 - i
+		path[j] = PathStep{Ordinal: ord, IsRoot: ord == target}
+	}
+	return path, true
+}
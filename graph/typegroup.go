@@ -0,0 +1,42 @@
+package graph
+
+// unknownType is the bucket name for objects with no resolved type. The
+// Go 1.4 heapdump format never tags an ordinary object with its dynamic
+// type, so in practice almost every object falls into this bucket; see
+// the type-resolution comment in Build.
+const unknownType = "<unknown>"
+
+// TypeStats are the aggregate counts and bytes for every object grouped
+// under a single type name.
+type TypeStats struct {
+	Name  string
+	Count int
+	Bytes uint64
+}
+
+// GroupByType groups every object in the graph by its resolved type
+// name (or a synthetic "<unknown>" bucket), with aggregate object counts
+// and shallow bytes per group.
+func (g *Graph) GroupByType() []TypeStats {
+	byName := make(map[string]*TypeStats)
+	var order []string
+	for ord := range g.objects {
+		name := unknownType
+		if t := g.objects[ord].Type; t != nil {
+			name = t.Name
+		}
+		s, ok := byName[name]
+		if !ok {
+			s = &TypeStats{Name: name}
+			byName[name] = s
+			order = append(order, name)
+		}
+		s.Count++
+		s.Bytes += g.shallowSize(int32(ord))
+	}
+	stats := make([]TypeStats, len(order))
+	for i, name := range order {
+		stats[i] = *byName[name]
+	}
+	return stats
+}
@@ -1,311 +1,88 @@
 package main
 
-// https://github.com/golang/go/wiki/heapdump14
-
 import (
-	"time"
-    "bytes"
-	"encoding/binary"
-	"errors"
-	"io"
-)
-
-type fieldKind int
-type tag int
-
-type field struct {
-	kind fieldKind
-	ptr  uint64
-}
-
-var header = []byte("go1.4 heap dump\n")
+	"fmt"
+	"log"
+	"os"
 
-const (
-	fieldKindEol   fieldKind = 0
-	fieldKindPtr             = 1
-	fieldKindIface           = 2
-	fieldKindEface           = 3
+	"github.com/dullgiulio/hdump/parser"
+	"github.com/dullgiulio/hdump/pprof"
 )
 
-const (
-	tagEOF             tag = 0
-	tagObject              = 1
-	tagOtherRoot           = 2
-	tagType                = 3
-	tagGoroutine           = 4
-	tagStackFrame          = 5
-	tagParams              = 6
-	tagFinalizer           = 7
-	tagItab                = 8
-	tagOSThread            = 9
-	tagMemStats            = 10
-	tagQueuedFinalizer     = 11
-	tagData                = 12
-	tagBSS                 = 13
-	tagDefer               = 14
-	tagPanic               = 15
-	tagMemProf             = 16
-	tagAllocSample         = 17
-)
+// summary is a Visitor that tallies record counts for a quick overview
+// of a dump's contents.
+type summary struct {
+	parser.NopVisitor
+	objects    int
+	goroutines int
+	types      int
+}
 
-func readHeader(r io.ByteReader) error {
-	head := make([]byte, len(header))
-	if _, err := io.ReadFull(io.Reader(r), head); err != nil {
-		return nil
-	}
-	if bytes.Compare(header, head) != 0 {
-		return errors.New("invalid heapdump")
-	}
+func (s *summary) OnObject(_ *parser.Context, _ *parser.Object) error {
+	s.objects++
 	return nil
 }
 
-func readString(r io.ByteReader) (string, error) {
-	nbytes, err := binary.ReadUvarint(r)
-	if err != nil {
-		return "", err
-	}
-	buf := make([]byte, nbytes)
-	_, err = io.ReadFull(io.Reader(r), buf)
-	if err != nil {
-		return "", err
-	}
-	return string(buf), nil
+func (s *summary) OnGoroutine(_ *parser.Context, _ *parser.Goroutine) error {
+	s.goroutines++
+	return nil
 }
 
-func readFieldlist(r io.ByteReader) ([]field, error) {
-	fields := make([]field, 0)
-	for {
-		t, err := binary.ReadUvarint(r)
-		if err != nil {
-			return nil, err
-		}
-		if fieldKind(t) == fieldKindEol {
-			return fields, nil
-		}
-		v, err := binary.ReadUvarint(r)
-		if err != nil {
-			return nil, err
-		}
-		fields = append(fields, field{fieldKind(t), v})
-	}
+func (s *summary) OnType(_ *parser.Context, _ *parser.Type) error {
+	s.types++
+	return nil
 }
 
-type object struct {
-	addr     uint64
-	contents string
-	fields   []field
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: %s <heapdump file>\n       %s pprof <heapdump file> <profile file>\n", os.Args[0], os.Args[0])
+	os.Exit(2)
 }
 
-func readObject(r io.ByteReader) (*object, error) {
-	addr, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	contents, err := readString(r)
+func runSummary(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	fields, err := readFieldlist(r)
-	if err != nil {
-		return nil, err
-	}
-	return &object{addr, contents, fields}, nil
-}
+	defer f.Close()
 
-type root struct {
-	descr string
-	ptr   uint64
-}
-
-func readOtherRoot(r io.ByteReader) (*root, error) {
-	descr, err := readString(r)
-	if err != nil {
-		return nil, err
+	s := &summary{}
+	if err := parser.Parse(f, s); err != nil {
+		return err
 	}
-	ptr, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	return &root{descr, ptr}, nil
-}
-
-type Type struct {
-	addr  uint64
-	size  uint64
-	name  string
-	isPtr bool
+	fmt.Printf("objects: %d\ngoroutines: %d\ntypes: %d\n", s.objects, s.goroutines, s.types)
+	return nil
 }
 
-func readType(r io.ByteReader) (*Type, error) {
-	addr, err := binary.ReadUvarint(r)
+func runPprof(dumpPath, outPath string) error {
+	in, err := os.Open(dumpPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	size, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	name, err := readString(r)
-	if err != nil {
-		return nil, err
-	}
-	isptr, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	return &Type{addr, size, name, isptr != 0}, nil
-}
-
-type status int
-
-const (
-	statusIdle     status = 0
-	statusRunnable        = 1
-	statusSyscall         = 2
-	statusWaiting         = 3
-)
-
-type Goroutine struct {
-	addr         uint64
-	rframe       uint64
-	id           uint64
-	stmt         uint64
-	status       status
-	isSys        bool
-	isBackground bool
-	started      time.Time
-	waitReason   string
-	ctx          uint64
-	MThread      uint64
-	dfer         uint64
-	pnic         uint64
-}
+	defer in.Close()
 
-func readGoroutine(r io.ByteReader) (*Goroutine, error) {
-	var err error
-	g := &Goroutine{}
-	g.addr, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.rframe, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.id, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.stmt, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	st, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.status = status(st)
-	b, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.isSys = b != 1
-	b, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.isBackground = b != 1
-	started, err := binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.started = time.Unix(started/time.Second, started%time.Second)
-	g.waitReason, err = readString(r)
-	if err != nil {
-		return nil, err
-	}
-	g.ctx, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.MThread, err = binary.ReadUvarint(r)
+	out, err := os.Create(outPath)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	g.dfer, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	g.pnic, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	return g, nil
-}
+	defer out.Close()
 
-type stackFrame struct {
-	ptr        uint64
-	depth      uint64
-	childPtr   uint64
-	content    string
-	startPC    uint64
-	currentPC  uint64
-	continuePC uint64
-	name       string
-	fields     []field
+	return pprof.WriteTo(out, in)
 }
 
-func readStackFrame(r io.ByteReader) (*stackFrame, error) {
+func main() {
 	var err error
-	s := &stackFrame{}
-	s.ptr, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.depth, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.childPtr, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.content, err = readString(r)
-	if err != nil {
-		return nil, err
-	}
-	s.startPC, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.currentPC, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.continuePC, err = binary.ReadUvarint(r)
-	if err != nil {
-		return nil, err
-	}
-	s.name, err = readString(r)
-	if err != nil {
-		return nil, err
+	switch len(os.Args) {
+	case 2:
+		err = runSummary(os.Args[1])
+	case 4:
+		if os.Args[1] != "pprof" {
+			usage()
+		}
+		err = runPprof(os.Args[2], os.Args[3])
+	default:
+		usage()
 	}
-	s.fields, err = readFieldlist(r)
 	if err != nil {
-		return nil, err
+		log.Fatal(err)
 	}
-	return s, nil
 }
-
-// dump params
-// finalizer
-// itab
-// osthread
-// memstats
-// queuedfinalizer
-// data
-// bss
-// defer
-// panic
-// alloc/free profile record
-// alloc sample record
@@ -0,0 +1,35 @@
+//go:build !windows
+
+package parser
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapSource is a dataSource backed by an mmap'd file, giving
+// Object.Contents and Segment.Contents zero-copy access into the
+// original dump instead of a buffered copy.
+type mmapSource struct {
+	data []byte
+}
+
+func openMmap(f *os.File) (*mmapSource, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(info.Size()), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapSource{data: data}, nil
+}
+
+func (m *mmapSource) Slice(offset, length uint64) []byte {
+	return m.data[offset : offset+length]
+}
+
+func (m *mmapSource) Close() error {
+	return syscall.Munmap(m.data)
+}
@@ -0,0 +1,20 @@
+//go:build windows
+
+package parser
+
+import (
+	"errors"
+	"os"
+)
+
+// mmapSource is unimplemented on Windows; ParseOptions.Mmap reports an
+// error rather than silently falling back to buffering.
+type mmapSource struct{}
+
+func openMmap(f *os.File) (*mmapSource, error) {
+	return nil, errors.New("parser: mmap is not supported on this platform")
+}
+
+func (m *mmapSource) Slice(offset, length uint64) []byte { return nil }
+
+func (m *mmapSource) Close() error { return nil }
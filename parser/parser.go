@@ -0,0 +1,353 @@
+// Package parser decodes the Go 1.4 heap dump format.
+//
+// https://github.com/golang/go/wiki/heapdump14
+package parser
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// FieldKind identifies what a pointer offset within an object, stack
+// frame, data or bss segment refers to.
+type FieldKind int
+
+type tag int
+
+// Field is a single pointer-bearing offset within a record's contents.
+type Field struct {
+	Kind FieldKind
+	Ptr  uint64
+}
+
+var header = []byte("go1.4 heap dump\n")
+
+// byteReader is the minimal interface the record readers need: a
+// bufio.Reader satisfies it directly, without requiring an io.Reader to
+// io.ByteReader conversion that the standard library does not support.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// dataSource provides zero-copy access to record contents that
+// streaming mode chose not to buffer, addressed by absolute byte offset
+// into the original dump. An mmap'd file is the only implementation.
+type dataSource interface {
+	Slice(offset, length uint64) []byte
+}
+
+// ParseOptions controls how Parse handles large record contents
+// (objects and data/bss segments).
+type ParseOptions struct {
+	// Streaming avoids buffering object and segment contents in memory;
+	// Object.Contents and Segment.Contents return nil unless Mmap is
+	// also set, since nothing kept the bytes around to return.
+	Streaming bool
+	// Mmap backs Object.Contents and Segment.Contents with a zero-copy
+	// slice into an mmap'd copy of the dump file. Only meaningful
+	// together with Streaming, and only usable via ParseFile.
+	Mmap bool
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+const (
+	FieldKindEol   FieldKind = 0
+	FieldKindPtr             = 1
+	FieldKindIface           = 2
+	FieldKindEface           = 3
+)
+
+const (
+	tagEOF             tag = 0
+	tagObject              = 1
+	tagOtherRoot           = 2
+	tagType                = 3
+	tagGoroutine           = 4
+	tagStackFrame          = 5
+	tagParams              = 6
+	tagFinalizer           = 7
+	tagItab                = 8
+	tagOSThread            = 9
+	tagMemStats            = 10
+	tagQueuedFinalizer     = 11
+	tagData                = 12
+	tagBSS                 = 13
+	tagDefer               = 14
+	tagPanic               = 15
+	tagMemProf             = 16
+	tagAllocSample         = 17
+)
+
+// Context carries the dump-wide parameters needed to interpret records
+// correctly. It is populated from the Params record, which the format
+// guarantees appears before any record whose decoding depends on it, and
+// is passed to every Visitor callback.
+type Context struct {
+	Params Params
+}
+
+// Visitor receives one callback per record as Parse streams through a
+// heap dump, so callers can process arbitrarily large dumps without
+// buffering the whole decoded record set in memory.
+type Visitor interface {
+	OnObject(ctx *Context, o *Object) error
+	OnOtherRoot(ctx *Context, r *OtherRoot) error
+	OnType(ctx *Context, t *Type) error
+	OnGoroutine(ctx *Context, g *Goroutine) error
+	OnStackFrame(ctx *Context, s *StackFrame) error
+	OnParams(ctx *Context, p *Params) error
+	OnFinalizer(ctx *Context, f *Finalizer) error
+	OnItab(ctx *Context, i *Itab) error
+	OnOSThread(ctx *Context, t *OSThread) error
+	OnMemStats(ctx *Context, m *MemStats) error
+	OnQueuedFinalizer(ctx *Context, f *Finalizer) error
+	OnData(ctx *Context, d *Segment) error
+	OnBSS(ctx *Context, d *Segment) error
+	OnDefer(ctx *Context, d *Defer) error
+	OnPanic(ctx *Context, p *Panic) error
+	OnMemProfRecord(ctx *Context, m *MemProfRecord) error
+	OnAllocSample(ctx *Context, a *AllocSample) error
+}
+
+// NopVisitor implements Visitor with no-op methods, so callers can embed
+// it and override only the callbacks they care about.
+type NopVisitor struct{}
+
+func (NopVisitor) OnObject(*Context, *Object) error               { return nil }
+func (NopVisitor) OnOtherRoot(*Context, *OtherRoot) error         { return nil }
+func (NopVisitor) OnType(*Context, *Type) error                   { return nil }
+func (NopVisitor) OnGoroutine(*Context, *Goroutine) error         { return nil }
+func (NopVisitor) OnStackFrame(*Context, *StackFrame) error       { return nil }
+func (NopVisitor) OnParams(*Context, *Params) error               { return nil }
+func (NopVisitor) OnFinalizer(*Context, *Finalizer) error         { return nil }
+func (NopVisitor) OnItab(*Context, *Itab) error                   { return nil }
+func (NopVisitor) OnOSThread(*Context, *OSThread) error           { return nil }
+func (NopVisitor) OnMemStats(*Context, *MemStats) error           { return nil }
+func (NopVisitor) OnQueuedFinalizer(*Context, *Finalizer) error   { return nil }
+func (NopVisitor) OnData(*Context, *Segment) error                { return nil }
+func (NopVisitor) OnBSS(*Context, *Segment) error                 { return nil }
+func (NopVisitor) OnDefer(*Context, *Defer) error                 { return nil }
+func (NopVisitor) OnPanic(*Context, *Panic) error                 { return nil }
+func (NopVisitor) OnMemProfRecord(*Context, *MemProfRecord) error { return nil }
+func (NopVisitor) OnAllocSample(*Context, *AllocSample) error     { return nil }
+
+func readHeader(r byteReader) error {
+	head := make([]byte, len(header))
+	if _, err := io.ReadFull(r, head); err != nil {
+		return err
+	}
+	if !bytes.Equal(header, head) {
+		return errors.New("invalid heapdump")
+	}
+	return nil
+}
+
+// Parse reads a Go 1.4 heap dump from r, dispatching one Visitor callback
+// per record. It returns as soon as the tagEOF record is seen, an error is
+// returned by a callback, or the underlying reader fails.
+func Parse(r io.Reader, v Visitor) error {
+	return parse(r, nil, ParseOptions{}, v)
+}
+
+// ParseFile opens path and parses it as a heap dump, honoring opts. When
+// opts.Mmap is set, the returned io.Closer must be kept open for as long
+// as any Object or Segment handed to v may still have Contents called on
+// it; closing it unmaps the file and invalidates those slices.
+func ParseFile(path string, opts ParseOptions, v Visitor) (io.Closer, error) {
+	if opts.Mmap && !opts.Streaming {
+		return nil, errors.New("parser: ParseOptions.Mmap requires Streaming")
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	closer := io.Closer(f)
+
+	var src dataSource
+	if opts.Mmap {
+		m, err := openMmap(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		src = m
+		closer = closerFunc(func() error {
+			mErr := m.Close()
+			fErr := f.Close()
+			if mErr != nil {
+				return mErr
+			}
+			return fErr
+		})
+	}
+
+	if err := parse(f, src, opts, v); err != nil {
+		closer.Close()
+		return nil, err
+	}
+	return closer, nil
+}
+
+func parse(r io.Reader, src dataSource, opts ParseOptions, v Visitor) error {
+	br := newBufferedReader(r)
+	if err := readHeader(br); err != nil {
+		return err
+	}
+	ctx := &Context{}
+	for {
+		t, err := binary.ReadUvarint(br)
+		if err != nil {
+			return err
+		}
+		switch tag(t) {
+		case tagEOF:
+			return nil
+		case tagObject:
+			o, err := readObject(br, src, opts.Streaming)
+			if err != nil {
+				return err
+			}
+			if err := v.OnObject(ctx, o); err != nil {
+				return err
+			}
+		case tagOtherRoot:
+			o, err := readOtherRoot(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnOtherRoot(ctx, o); err != nil {
+				return err
+			}
+		case tagType:
+			tp, err := readType(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnType(ctx, tp); err != nil {
+				return err
+			}
+		case tagGoroutine:
+			g, err := readGoroutine(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnGoroutine(ctx, g); err != nil {
+				return err
+			}
+		case tagStackFrame:
+			s, err := readStackFrame(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnStackFrame(ctx, s); err != nil {
+				return err
+			}
+		case tagParams:
+			p, err := readParams(br)
+			if err != nil {
+				return err
+			}
+			ctx.Params = *p
+			if err := v.OnParams(ctx, p); err != nil {
+				return err
+			}
+		case tagFinalizer:
+			f, err := readFinalizer(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnFinalizer(ctx, f); err != nil {
+				return err
+			}
+		case tagItab:
+			i, err := readItab(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnItab(ctx, i); err != nil {
+				return err
+			}
+		case tagOSThread:
+			th, err := readOSThread(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnOSThread(ctx, th); err != nil {
+				return err
+			}
+		case tagMemStats:
+			m, err := readMemStats(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnMemStats(ctx, m); err != nil {
+				return err
+			}
+		case tagQueuedFinalizer:
+			f, err := readFinalizer(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnQueuedFinalizer(ctx, f); err != nil {
+				return err
+			}
+		case tagData:
+			d, err := readSegment(br, src, opts.Streaming)
+			if err != nil {
+				return err
+			}
+			if err := v.OnData(ctx, d); err != nil {
+				return err
+			}
+		case tagBSS:
+			d, err := readSegment(br, src, opts.Streaming)
+			if err != nil {
+				return err
+			}
+			if err := v.OnBSS(ctx, d); err != nil {
+				return err
+			}
+		case tagDefer:
+			d, err := readDefer(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnDefer(ctx, d); err != nil {
+				return err
+			}
+		case tagPanic:
+			p, err := readPanic(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnPanic(ctx, p); err != nil {
+				return err
+			}
+		case tagMemProf:
+			m, err := readMemProfRecord(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnMemProfRecord(ctx, m); err != nil {
+				return err
+			}
+		case tagAllocSample:
+			a, err := readAllocSample(br)
+			if err != nil {
+				return err
+			}
+			if err := v.OnAllocSample(ctx, a); err != nil {
+				return err
+			}
+		default:
+			return errors.New("unknown heapdump tag")
+		}
+	}
+}
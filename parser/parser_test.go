@@ -0,0 +1,107 @@
+package parser_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dullgiulio/hdump/parser"
+	"github.com/dullgiulio/hdump/writer"
+)
+
+type collector struct {
+	parser.NopVisitor
+	objects []*parser.Object
+}
+
+func (c *collector) OnObject(_ *parser.Context, o *parser.Object) error {
+	c.objects = append(c.objects, o)
+	return nil
+}
+
+func writeDump(t *testing.T, path string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	defer f.Close()
+
+	w, err := writer.NewWriter(f)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteParams(false, 8, 0, 0, "amd64", "", 1); err != nil {
+		t.Fatalf("WriteParams: %v", err)
+	}
+	if err := w.WriteObject(0xc000010000, []byte("streamed contents"), nil); err != nil {
+		t.Fatalf("WriteObject: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+// TestParseFileMmap checks that streaming+mmap mode reproduces the same
+// object contents as the default buffered mode, via a zero-copy slice
+// into the mapped file rather than a buffered copy.
+func TestParseFileMmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump")
+	writeDump(t, path)
+
+	c := &collector{}
+	closer, err := parser.ParseFile(path, parser.ParseOptions{Streaming: true, Mmap: true}, c)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	defer closer.Close()
+
+	if len(c.objects) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(c.objects))
+	}
+	o := c.objects[0]
+	if o.Size() != uint64(len("streamed contents")) {
+		t.Errorf("Size() = %d, want %d", o.Size(), len("streamed contents"))
+	}
+	if got := string(o.Contents()); got != "streamed contents" {
+		t.Errorf("Contents() = %q, want %q", got, "streamed contents")
+	}
+}
+
+// TestParseFileMmapRequiresStreaming checks that Mmap without Streaming
+// is rejected up front, rather than silently mapping the whole file and
+// then buffering every record's contents anyway.
+func TestParseFileMmapRequiresStreaming(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump")
+	writeDump(t, path)
+
+	_, err := parser.ParseFile(path, parser.ParseOptions{Mmap: true}, &collector{})
+	if err == nil {
+		t.Fatal("expected an error for Mmap without Streaming, got nil")
+	}
+}
+
+// TestParseFileStreamingWithoutMmap checks that Size is still accurate
+// when contents are skipped entirely rather than buffered or mmap'd.
+func TestParseFileStreamingWithoutMmap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dump")
+	writeDump(t, path)
+
+	c := &collector{}
+	closer, err := parser.ParseFile(path, parser.ParseOptions{Streaming: true}, c)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	defer closer.Close()
+
+	if len(c.objects) != 1 {
+		t.Fatalf("expected 1 object, got %d", len(c.objects))
+	}
+	o := c.objects[0]
+	if o.Size() != uint64(len("streamed contents")) {
+		t.Errorf("Size() = %d, want %d", o.Size(), len("streamed contents"))
+	}
+	if o.Contents() != nil {
+		t.Errorf("Contents() = %q, want nil since nothing was buffered or mapped", o.Contents())
+	}
+}
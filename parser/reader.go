@@ -0,0 +1,44 @@
+package parser
+
+import (
+	"bufio"
+	"io"
+)
+
+// bufferedReader wraps a bufio.Reader and tracks the absolute number of
+// bytes consumed from the underlying io.Reader, so streaming mode can
+// record (offset, length) descriptors for large record contents instead
+// of copying them into memory.
+type bufferedReader struct {
+	r   *bufio.Reader
+	off uint64
+}
+
+func newBufferedReader(r io.Reader) *bufferedReader {
+	return &bufferedReader{r: bufio.NewReader(r)}
+}
+
+func (b *bufferedReader) ReadByte() (byte, error) {
+	c, err := b.r.ReadByte()
+	if err == nil {
+		b.off++
+	}
+	return c, err
+}
+
+func (b *bufferedReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	b.off += uint64(n)
+	return n, err
+}
+
+// Offset returns the absolute number of bytes consumed so far.
+func (b *bufferedReader) Offset() uint64 { return b.off }
+
+// Discard skips the next n bytes without buffering them, advancing
+// Offset as if they had been read.
+func (b *bufferedReader) Discard(n int) error {
+	d, err := b.r.Discard(n)
+	b.off += uint64(d)
+	return err
+}
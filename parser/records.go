@@ -0,0 +1,684 @@
+package parser
+
+import (
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+func readString(r byteReader) (string, error) {
+	nbytes, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	buf := make([]byte, nbytes)
+	_, err = io.ReadFull(r, buf)
+	if err != nil {
+		return "", err
+	}
+	return string(buf), nil
+}
+
+func readFieldlist(r byteReader) ([]Field, error) {
+	fields := make([]Field, 0)
+	for {
+		t, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if FieldKind(t) == FieldKindEol {
+			return fields, nil
+		}
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		fields = append(fields, Field{FieldKind(t), v})
+	}
+}
+
+// contentsHolder backs the Contents/Size methods shared by Object and
+// Segment: in the default mode it holds a buffered copy, and in
+// streaming+mmap mode it instead holds an (offset, length) descriptor
+// resolved lazily against a dataSource, avoiding a buffered copy.
+type contentsHolder struct {
+	data   []byte
+	source dataSource
+	offset uint64
+	length uint64
+}
+
+// Contents returns the record's raw bytes. In streaming mode without
+// Mmap, nothing was kept and Contents returns nil. The returned slice is
+// not a copy: in Mmap mode it aliases the read-only mapped file, and in
+// buffered mode it aliases this record's only copy, so callers must not
+// write to it.
+func (c *contentsHolder) Contents() []byte {
+	if c.source != nil {
+		return c.source.Slice(c.offset, c.length)
+	}
+	return c.data
+}
+
+// Size returns the byte length of Contents, which remains accurate even
+// in streaming mode without Mmap, where Contents itself returns nil.
+func (c *contentsHolder) Size() uint64 {
+	if c.data != nil {
+		return uint64(len(c.data))
+	}
+	return c.length
+}
+
+// readContents reads a length-prefixed byte blob, buffering it unless
+// streaming is set, in which case it is skipped and only a descriptor
+// into src is kept.
+func readContents(r *bufferedReader, src dataSource, streaming bool) (contentsHolder, error) {
+	nbytes, err := binary.ReadUvarint(r)
+	if err != nil {
+		return contentsHolder{}, err
+	}
+	if streaming {
+		off := r.Offset()
+		if err := r.Discard(int(nbytes)); err != nil {
+			return contentsHolder{}, err
+		}
+		return contentsHolder{source: src, offset: off, length: nbytes}, nil
+	}
+	buf := make([]byte, nbytes)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return contentsHolder{}, err
+	}
+	return contentsHolder{data: buf}, nil
+}
+
+// Object is a single heap-allocated value: its address, raw contents and
+// the offsets within those contents that hold pointers.
+type Object struct {
+	Addr   uint64
+	Fields []Field
+	contentsHolder
+}
+
+func readObject(r *bufferedReader, src dataSource, streaming bool) (*Object, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := readContents(r, src, streaming)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := readFieldlist(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{Addr: addr, Fields: fields, contentsHolder: ch}, nil
+}
+
+// OtherRoot is a GC root that the runtime could not attribute to data,
+// bss, or a goroutine stack, along with a human-readable description.
+type OtherRoot struct {
+	Descr string
+	Ptr   uint64
+}
+
+func readOtherRoot(r byteReader) (*OtherRoot, error) {
+	descr, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	ptr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return &OtherRoot{descr, ptr}, nil
+}
+
+// Type describes a Go type that appears somewhere in the dump.
+type Type struct {
+	Addr  uint64
+	Size  uint64
+	Name  string
+	IsPtr bool
+}
+
+func readType(r byteReader) (*Type, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	name, err := readString(r)
+	if err != nil {
+		return nil, err
+	}
+	isptr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Type{addr, size, name, isptr != 0}, nil
+}
+
+// Status is a goroutine's scheduling state, as recorded in a Goroutine
+// record.
+type Status int
+
+const (
+	StatusIdle     Status = 0
+	StatusRunnable        = 1
+	StatusSyscall         = 2
+	StatusWaiting         = 3
+)
+
+// Goroutine is a single goroutine at the time of the dump.
+type Goroutine struct {
+	Addr         uint64
+	Rframe       uint64
+	ID           uint64
+	Stmt         uint64
+	Status       Status
+	IsSys        bool
+	IsBackground bool
+	Started      time.Time
+	WaitReason   string
+	Ctx          uint64
+	MThread      uint64
+	Dfer         uint64
+	Pnic         uint64
+}
+
+func readGoroutine(r byteReader) (*Goroutine, error) {
+	var err error
+	g := &Goroutine{}
+	g.Addr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Rframe, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.ID, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Stmt, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	st, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Status = Status(st)
+	b, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.IsSys = b == 1
+	b, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.IsBackground = b == 1
+	started, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Started = time.Unix(0, int64(started))
+	g.WaitReason, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Ctx, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.MThread, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Dfer, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	g.Pnic, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return g, nil
+}
+
+// StackFrame is one frame of a goroutine's stack.
+type StackFrame struct {
+	Ptr        uint64
+	Depth      uint64
+	ChildPtr   uint64
+	Content    string
+	StartPC    uint64
+	CurrentPC  uint64
+	ContinuePC uint64
+	Name       string
+	Fields     []Field
+}
+
+func readStackFrame(r byteReader) (*StackFrame, error) {
+	var err error
+	s := &StackFrame{}
+	s.Ptr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Depth, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.ChildPtr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Content, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
+	s.StartPC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.CurrentPC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.ContinuePC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Name, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
+	s.Fields, err = readFieldlist(r)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Params is the dump-wide params record. It is always the first record
+// after the header, so that its pointer size and endianness can be used
+// to interpret every record that follows.
+type Params struct {
+	BigEndian    bool
+	PtrSize      uint64
+	HeapStart    uint64
+	HeapEnd      uint64
+	Arch         string
+	GoExperiment string
+	NCPU         uint64
+}
+
+func readParams(r byteReader) (*Params, error) {
+	p := &Params{}
+	be, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.BigEndian = be != 0
+	p.PtrSize, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.HeapStart, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.HeapEnd, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Arch, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
+	p.GoExperiment, err = readString(r)
+	if err != nil {
+		return nil, err
+	}
+	p.NCPU, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Finalizer describes a registered finalizer, pending or queued for
+// execution. Both tagFinalizer and tagQueuedFinalizer share this shape.
+type Finalizer struct {
+	Obj     uint64
+	FinFunc uint64
+	PC      uint64
+	FinType uint64
+	ObjType uint64
+}
+
+func readFinalizer(r byteReader) (*Finalizer, error) {
+	f := &Finalizer{}
+	var err error
+	f.Obj, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	f.FinFunc, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	f.PC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	f.FinType, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	f.ObjType, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Itab maps an interface table address to the concrete type it describes.
+type Itab struct {
+	Addr uint64
+	Type uint64
+}
+
+func readItab(r byteReader) (*Itab, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	typ, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Itab{addr, typ}, nil
+}
+
+// OSThread is an OS-level thread (M) running at dump time.
+type OSThread struct {
+	Addr uint64
+	ID   uint64
+	OS   uint64
+}
+
+func readOSThread(r byteReader) (*OSThread, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	id, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	os, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return &OSThread{addr, id, os}, nil
+}
+
+// numPauses is the size of the runtime.MemStats circular pause-time
+// buffer at the time the Go 1.4 heapdump format was frozen.
+const numPauses = 256
+
+// MemStats mirrors the subset of runtime.MemStats dumped in a
+// tagMemStats record, in field order.
+type MemStats struct {
+	Alloc        uint64
+	TotalAlloc   uint64
+	Sys          uint64
+	Lookups      uint64
+	Mallocs      uint64
+	Frees        uint64
+	HeapAlloc    uint64
+	HeapSys      uint64
+	HeapIdle     uint64
+	HeapInuse    uint64
+	HeapReleased uint64
+	HeapObjects  uint64
+	StackInuse   uint64
+	StackSys     uint64
+	MSpanInuse   uint64
+	MSpanSys     uint64
+	MCacheInuse  uint64
+	MCacheSys    uint64
+	BuckHashSys  uint64
+	GCSys        uint64
+	OtherSys     uint64
+	NextGC       uint64
+	LastGC       uint64
+	PauseTotalNs uint64
+	PauseNs      [numPauses]uint64
+	NumGC        uint64
+}
+
+func readMemStats(r byteReader) (*MemStats, error) {
+	m := &MemStats{}
+	fields := []*uint64{
+		&m.Alloc, &m.TotalAlloc, &m.Sys, &m.Lookups, &m.Mallocs, &m.Frees,
+		&m.HeapAlloc, &m.HeapSys, &m.HeapIdle, &m.HeapInuse, &m.HeapReleased, &m.HeapObjects,
+		&m.StackInuse, &m.StackSys, &m.MSpanInuse, &m.MSpanSys, &m.MCacheInuse, &m.MCacheSys,
+		&m.BuckHashSys, &m.GCSys, &m.OtherSys, &m.NextGC, &m.LastGC, &m.PauseTotalNs,
+	}
+	for _, f := range fields {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		*f = v
+	}
+	for i := range m.PauseNs {
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		m.PauseNs[i] = v
+	}
+	v, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	m.NumGC = v
+	return m, nil
+}
+
+// Segment is a data or bss segment: a contiguous range of global memory
+// together with the offsets within it that hold pointers.
+type Segment struct {
+	Addr   uint64
+	Fields []Field
+	contentsHolder
+}
+
+func readSegment(r *bufferedReader, src dataSource, streaming bool) (*Segment, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	ch, err := readContents(r, src, streaming)
+	if err != nil {
+		return nil, err
+	}
+	fields, err := readFieldlist(r)
+	if err != nil {
+		return nil, err
+	}
+	return &Segment{Addr: addr, Fields: fields, contentsHolder: ch}, nil
+}
+
+// Defer is a single deferred call on a goroutine's defer chain.
+type Defer struct {
+	Addr      uint64
+	Goroutine uint64
+	Argp      uint64
+	PC        uint64
+	FuncVal   uint64
+	FuncPC    uint64
+	Link      uint64
+}
+
+func readDefer(r byteReader) (*Defer, error) {
+	d := &Defer{}
+	var err error
+	d.Addr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.Goroutine, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.Argp, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.PC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.FuncVal, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.FuncPC, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	d.Link, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Panic is a single entry on a goroutine's panic chain.
+type Panic struct {
+	Addr      uint64
+	Goroutine uint64
+	Type      uint64
+	Data      uint64
+	Defer     uint64
+	Link      uint64
+}
+
+func readPanic(r byteReader) (*Panic, error) {
+	p := &Panic{}
+	var err error
+	p.Addr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Goroutine, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Type, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Data, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Defer, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	p.Link, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// MemProfFrame is one call-stack frame attached to a MemProfRecord.
+type MemProfFrame struct {
+	Func string
+	File string
+	Line uint64
+}
+
+// MemProfRecord is a memory-profiling bucket: an allocation site and its
+// observed allocation/free counts.
+type MemProfRecord struct {
+	Addr   uint64
+	Size   uint64
+	Frames []MemProfFrame
+	Allocs uint64
+	Frees  uint64
+}
+
+func readMemProfRecord(r byteReader) (*MemProfRecord, error) {
+	m := &MemProfRecord{}
+	var err error
+	m.Addr, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	m.Size, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	nframes, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	m.Frames = make([]MemProfFrame, nframes)
+	for i := range m.Frames {
+		fn, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		file, err := readString(r)
+		if err != nil {
+			return nil, err
+		}
+		line, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		m.Frames[i] = MemProfFrame{fn, file, line}
+	}
+	m.Allocs, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	m.Frees, err = binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// AllocSample ties a live object to the MemProfRecord bucket that
+// recorded its allocation site.
+type AllocSample struct {
+	Addr   uint64
+	Bucket uint64
+}
+
+func readAllocSample(r byteReader) (*AllocSample, error) {
+	addr, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	bucket, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+	return &AllocSample{addr, bucket}, nil
+}
@@ -0,0 +1,107 @@
+// Package pprof converts a parsed Go 1.4 heap dump into a pprof heap
+// profile, so it can be fed straight into `go tool pprof` for
+// flamegraphs and top-N views.
+package pprof
+
+import (
+	"io"
+
+	"github.com/dullgiulio/hdump/parser"
+	"github.com/google/pprof/profile"
+)
+
+type collector struct {
+	parser.NopVisitor
+
+	buckets []*parser.MemProfRecord
+	samples []*parser.AllocSample
+}
+
+func (c *collector) OnMemProfRecord(_ *parser.Context, m *parser.MemProfRecord) error {
+	c.buckets = append(c.buckets, m)
+	return nil
+}
+
+func (c *collector) OnAllocSample(_ *parser.Context, a *parser.AllocSample) error {
+	c.samples = append(c.samples, a)
+	return nil
+}
+
+// Convert parses r as a heap dump and builds a pprof Profile with
+// inuse_objects/inuse_bytes samples, one per allocation-site bucket
+// recorded in the dump's MemProf records. Locations and functions are
+// derived from each bucket's captured call stack.
+func Convert(r io.Reader) (*profile.Profile, error) {
+	c := &collector{}
+	if err := parser.Parse(r, c); err != nil {
+		return nil, err
+	}
+
+	p := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "inuse_objects", Unit: "count"},
+			{Type: "inuse_bytes", Unit: "bytes"},
+		},
+		PeriodType: &profile.ValueType{Type: "space", Unit: "bytes"},
+		Period:     1,
+	}
+
+	funcs := make(map[string]*profile.Function)
+	funcByID := func(name string) *profile.Function {
+		if fn, ok := funcs[name]; ok {
+			return fn
+		}
+		fn := &profile.Function{
+			ID:   uint64(len(p.Function) + 1),
+			Name: name,
+		}
+		funcs[name] = fn
+		p.Function = append(p.Function, fn)
+		return fn
+	}
+
+	// liveObjects[bucket addr] counts the AllocSample records pointing at
+	// each bucket, i.e. the objects actually still live in the dump,
+	// which is more precise than the bucket's lifetime Allocs/Frees
+	// counters for an inuse_objects sample.
+	liveObjects := make(map[uint64]int64, len(c.samples))
+	for _, s := range c.samples {
+		liveObjects[s.Bucket]++
+	}
+
+	for _, b := range c.buckets {
+		locs := make([]*profile.Location, 0, len(b.Frames))
+		for _, fr := range b.Frames {
+			fn := funcByID(fr.Func)
+			loc := &profile.Location{
+				ID: uint64(len(p.Location) + 1),
+				Line: []profile.Line{
+					{Function: fn, Line: int64(fr.Line)},
+				},
+			}
+			p.Location = append(p.Location, loc)
+			locs = append(locs, loc)
+		}
+
+		inuse := liveObjects[b.Addr]
+		if inuse == 0 && b.Allocs > b.Frees {
+			inuse = int64(b.Allocs - b.Frees)
+		}
+		p.Sample = append(p.Sample, &profile.Sample{
+			Location: locs,
+			Value:    []int64{inuse, inuse * int64(b.Size)},
+		})
+	}
+
+	return p, nil
+}
+
+// WriteTo converts r and writes the resulting profile to w in the
+// gzipped protobuf format `go tool pprof` expects.
+func WriteTo(w io.Writer, r io.Reader) error {
+	p, err := Convert(r)
+	if err != nil {
+		return err
+	}
+	return p.Write(w)
+}
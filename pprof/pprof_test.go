@@ -0,0 +1,56 @@
+package pprof_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dullgiulio/hdump/pprof"
+	"github.com/dullgiulio/hdump/writer"
+)
+
+func TestConvert(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := writer.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteParams(false, 8, 0, 0, "amd64", "", 1); err != nil {
+		t.Fatalf("WriteParams: %v", err)
+	}
+	if err := w.WriteMemProfRecord(0x1, 32, []writer.MemProfFrame{
+		{Func: "main.allocate", File: "main.go", Line: 10},
+	}, 5, 2); err != nil {
+		t.Fatalf("WriteMemProfRecord: %v", err)
+	}
+	if err := w.WriteAllocSample(0xc000000100, 0x1); err != nil {
+		t.Fatalf("WriteAllocSample: %v", err)
+	}
+	if err := w.WriteAllocSample(0xc000000200, 0x1); err != nil {
+		t.Fatalf("WriteAllocSample: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	p, err := pprof.Convert(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	if len(p.Sample) != 1 {
+		t.Fatalf("expected 1 sample, got %d", len(p.Sample))
+	}
+	s := p.Sample[0]
+	if s.Value[0] != 2 {
+		t.Errorf("inuse_objects = %d, want 2 (two live AllocSample records)", s.Value[0])
+	}
+	if s.Value[1] != 64 {
+		t.Errorf("inuse_bytes = %d, want 64 (2 objects * 32 bytes)", s.Value[1])
+	}
+	if len(s.Location) != 1 || s.Location[0].Line[0].Function.Name != "main.allocate" {
+		t.Fatalf("unexpected location: %+v", s.Location)
+	}
+
+	if err := p.CheckValid(); err != nil {
+		t.Fatalf("CheckValid: %v", err)
+	}
+}
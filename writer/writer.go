@@ -0,0 +1,502 @@
+// Package writer emits the Go 1.4 heap dump format read by
+// github.com/dullgiulio/hdump/parser: a header followed by
+// uvarint-tagged records and a final EOF tag. It exists to build
+// synthetic or reduced dumps for testing the parser, and is kept
+// independent of it so a dump can be authored without parsing one first.
+package writer
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"time"
+)
+
+var header = []byte("go1.4 heap dump\n")
+
+type tag int
+
+const (
+	tagEOF             tag = 0
+	tagObject              = 1
+	tagOtherRoot           = 2
+	tagType                = 3
+	tagGoroutine           = 4
+	tagStackFrame          = 5
+	tagParams              = 6
+	tagFinalizer           = 7
+	tagItab                = 8
+	tagOSThread            = 9
+	tagMemStats            = 10
+	tagQueuedFinalizer     = 11
+	tagData                = 12
+	tagBSS                 = 13
+	tagDefer               = 14
+	tagPanic               = 15
+	tagMemProf             = 16
+	tagAllocSample         = 17
+)
+
+// FieldKind identifies what a pointer offset within an object, stack
+// frame, data or bss segment refers to.
+type FieldKind int
+
+const (
+	FieldKindEol   FieldKind = 0
+	FieldKindPtr             = 1
+	FieldKindIface           = 2
+	FieldKindEface           = 3
+)
+
+// Field is a single pointer-bearing offset within a record's contents.
+type Field struct {
+	Kind FieldKind
+	Ptr  uint64
+}
+
+// Status is a goroutine's scheduling state, as recorded in a Goroutine
+// record.
+type Status int
+
+const (
+	StatusIdle     Status = 0
+	StatusRunnable        = 1
+	StatusSyscall         = 2
+	StatusWaiting         = 3
+)
+
+// MemProfFrame is one call-stack frame attached to a MemProf record.
+type MemProfFrame struct {
+	Func string
+	File string
+	Line uint64
+}
+
+// Writer emits a Go 1.4 heap dump record by record. Callers must write
+// the header first via NewWriter, then any number of records in any
+// order the format allows, and finally call Close to emit the EOF tag
+// and flush the underlying writer.
+type Writer struct {
+	bw *bufio.Writer
+}
+
+// NewWriter wraps w and writes the heap dump header immediately, so
+// every Writer is ready to accept records.
+func NewWriter(w io.Writer) (*Writer, error) {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.Write(header); err != nil {
+		return nil, err
+	}
+	return &Writer{bw}, nil
+}
+
+// Close writes the EOF tag and flushes any buffered output.
+func (w *Writer) Close() error {
+	if err := w.writeTag(tagEOF); err != nil {
+		return err
+	}
+	return w.bw.Flush()
+}
+
+func (w *Writer) writeTag(t tag) error {
+	return w.writeUvarint(uint64(t))
+}
+
+func (w *Writer) writeUvarint(v uint64) error {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	_, err := w.bw.Write(buf[:n])
+	return err
+}
+
+func (w *Writer) writeBool(b bool) error {
+	if b {
+		return w.writeUvarint(1)
+	}
+	return w.writeUvarint(0)
+}
+
+func (w *Writer) writeString(s string) error {
+	if err := w.writeUvarint(uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w.bw, s)
+	return err
+}
+
+func (w *Writer) writeBytes(b []byte) error {
+	if err := w.writeUvarint(uint64(len(b))); err != nil {
+		return err
+	}
+	_, err := w.bw.Write(b)
+	return err
+}
+
+func (w *Writer) writeFields(fields []Field) error {
+	for _, f := range fields {
+		if err := w.writeUvarint(uint64(f.Kind)); err != nil {
+			return err
+		}
+		if err := w.writeUvarint(f.Ptr); err != nil {
+			return err
+		}
+	}
+	return w.writeUvarint(uint64(FieldKindEol))
+}
+
+// WriteParams writes the dump-wide params record. It must be the first
+// record written, before any record whose decoding depends on pointer
+// size or endianness.
+func (w *Writer) WriteParams(bigEndian bool, ptrSize, heapStart, heapEnd uint64, arch, goExperiment string, ncpu uint64) error {
+	if err := w.writeTag(tagParams); err != nil {
+		return err
+	}
+	if err := w.writeBool(bigEndian); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(ptrSize); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(heapStart); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(heapEnd); err != nil {
+		return err
+	}
+	if err := w.writeString(arch); err != nil {
+		return err
+	}
+	if err := w.writeString(goExperiment); err != nil {
+		return err
+	}
+	return w.writeUvarint(ncpu)
+}
+
+// WriteObject writes a single heap-allocated value.
+func (w *Writer) WriteObject(addr uint64, contents []byte, fields []Field) error {
+	if err := w.writeTag(tagObject); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeBytes(contents); err != nil {
+		return err
+	}
+	return w.writeFields(fields)
+}
+
+// WriteOtherRoot writes a GC root the runtime could not attribute to
+// data, bss, or a goroutine stack.
+func (w *Writer) WriteOtherRoot(descr string, ptr uint64) error {
+	if err := w.writeTag(tagOtherRoot); err != nil {
+		return err
+	}
+	if err := w.writeString(descr); err != nil {
+		return err
+	}
+	return w.writeUvarint(ptr)
+}
+
+// WriteType writes a Go type.
+func (w *Writer) WriteType(addr, size uint64, name string, isPtr bool) error {
+	if err := w.writeTag(tagType); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(size); err != nil {
+		return err
+	}
+	if err := w.writeString(name); err != nil {
+		return err
+	}
+	isptr := uint64(0)
+	if isPtr {
+		isptr = 1
+	}
+	return w.writeUvarint(isptr)
+}
+
+// WriteGoroutine writes a single goroutine.
+func (w *Writer) WriteGoroutine(addr, rframe, id, stmt uint64, st Status, isSys, isBackground bool, started time.Time, waitReason string, ctx, mthread, dfer, pnic uint64) error {
+	if err := w.writeTag(tagGoroutine); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(rframe); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(id); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(stmt); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(uint64(st)); err != nil {
+		return err
+	}
+	if err := w.writeBool(isSys); err != nil {
+		return err
+	}
+	if err := w.writeBool(isBackground); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(uint64(started.UnixNano())); err != nil {
+		return err
+	}
+	if err := w.writeString(waitReason); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(ctx); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(mthread); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(dfer); err != nil {
+		return err
+	}
+	return w.writeUvarint(pnic)
+}
+
+// WriteStackFrame writes a single goroutine stack frame.
+func (w *Writer) WriteStackFrame(ptr, depth, childPtr uint64, content []byte, startPC, currentPC, continuePC uint64, name string, fields []Field) error {
+	if err := w.writeTag(tagStackFrame); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(ptr); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(depth); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(childPtr); err != nil {
+		return err
+	}
+	if err := w.writeBytes(content); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(startPC); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(currentPC); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(continuePC); err != nil {
+		return err
+	}
+	if err := w.writeString(name); err != nil {
+		return err
+	}
+	return w.writeFields(fields)
+}
+
+func (w *Writer) writeFinalizerLike(t tag, obj, finFunc, pc, finType, objType uint64) error {
+	if err := w.writeTag(t); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(obj); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(finFunc); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(pc); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(finType); err != nil {
+		return err
+	}
+	return w.writeUvarint(objType)
+}
+
+// WriteFinalizer writes a pending finalizer registration.
+func (w *Writer) WriteFinalizer(obj, finFunc, pc, finType, objType uint64) error {
+	return w.writeFinalizerLike(tagFinalizer, obj, finFunc, pc, finType, objType)
+}
+
+// WriteQueuedFinalizer writes a finalizer queued for execution. It has
+// the same shape as WriteFinalizer.
+func (w *Writer) WriteQueuedFinalizer(obj, finFunc, pc, finType, objType uint64) error {
+	return w.writeFinalizerLike(tagQueuedFinalizer, obj, finFunc, pc, finType, objType)
+}
+
+// WriteItab writes an interface table to concrete type mapping.
+func (w *Writer) WriteItab(addr, typ uint64) error {
+	if err := w.writeTag(tagItab); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	return w.writeUvarint(typ)
+}
+
+// WriteOSThread writes an OS-level thread.
+func (w *Writer) WriteOSThread(addr, id, os uint64) error {
+	if err := w.writeTag(tagOSThread); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(id); err != nil {
+		return err
+	}
+	return w.writeUvarint(os)
+}
+
+// numPauses mirrors the parser package: the size of the runtime.MemStats
+// circular pause-time buffer at the time the format was frozen.
+const numPauses = 256
+
+// MemStats mirrors the subset of runtime.MemStats written in a
+// MemStats record, in field order.
+type MemStats struct {
+	Alloc        uint64
+	TotalAlloc   uint64
+	Sys          uint64
+	Lookups      uint64
+	Mallocs      uint64
+	Frees        uint64
+	HeapAlloc    uint64
+	HeapSys      uint64
+	HeapIdle     uint64
+	HeapInuse    uint64
+	HeapReleased uint64
+	HeapObjects  uint64
+	StackInuse   uint64
+	StackSys     uint64
+	MSpanInuse   uint64
+	MSpanSys     uint64
+	MCacheInuse  uint64
+	MCacheSys    uint64
+	BuckHashSys  uint64
+	GCSys        uint64
+	OtherSys     uint64
+	NextGC       uint64
+	LastGC       uint64
+	PauseTotalNs uint64
+	PauseNs      [numPauses]uint64
+	NumGC        uint64
+}
+
+// WriteMemStats writes the runtime memory statistics snapshot.
+func (w *Writer) WriteMemStats(m MemStats) error {
+	if err := w.writeTag(tagMemStats); err != nil {
+		return err
+	}
+	fields := []uint64{
+		m.Alloc, m.TotalAlloc, m.Sys, m.Lookups, m.Mallocs, m.Frees,
+		m.HeapAlloc, m.HeapSys, m.HeapIdle, m.HeapInuse, m.HeapReleased, m.HeapObjects,
+		m.StackInuse, m.StackSys, m.MSpanInuse, m.MSpanSys, m.MCacheInuse, m.MCacheSys,
+		m.BuckHashSys, m.GCSys, m.OtherSys, m.NextGC, m.LastGC, m.PauseTotalNs,
+	}
+	for _, f := range fields {
+		if err := w.writeUvarint(f); err != nil {
+			return err
+		}
+	}
+	for _, p := range m.PauseNs {
+		if err := w.writeUvarint(p); err != nil {
+			return err
+		}
+	}
+	return w.writeUvarint(m.NumGC)
+}
+
+func (w *Writer) writeSegment(t tag, addr uint64, contents []byte, fields []Field) error {
+	if err := w.writeTag(t); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeBytes(contents); err != nil {
+		return err
+	}
+	return w.writeFields(fields)
+}
+
+// WriteData writes a global data segment.
+func (w *Writer) WriteData(addr uint64, contents []byte, fields []Field) error {
+	return w.writeSegment(tagData, addr, contents, fields)
+}
+
+// WriteBSS writes a global bss segment.
+func (w *Writer) WriteBSS(addr uint64, contents []byte, fields []Field) error {
+	return w.writeSegment(tagBSS, addr, contents, fields)
+}
+
+// WriteDefer writes a single deferred call on a goroutine's defer chain.
+func (w *Writer) WriteDefer(addr, goroutine, argp, pc, funcVal, funcPC, link uint64) error {
+	if err := w.writeTag(tagDefer); err != nil {
+		return err
+	}
+	for _, v := range []uint64{addr, goroutine, argp, pc, funcVal, funcPC, link} {
+		if err := w.writeUvarint(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WritePanic writes a single entry on a goroutine's panic chain.
+func (w *Writer) WritePanic(addr, goroutine, typ, data, deferAddr, link uint64) error {
+	if err := w.writeTag(tagPanic); err != nil {
+		return err
+	}
+	for _, v := range []uint64{addr, goroutine, typ, data, deferAddr, link} {
+		if err := w.writeUvarint(v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteMemProfRecord writes a memory-profiling bucket.
+func (w *Writer) WriteMemProfRecord(addr, size uint64, frames []MemProfFrame, allocs, frees uint64) error {
+	if err := w.writeTag(tagMemProf); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(size); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(uint64(len(frames))); err != nil {
+		return err
+	}
+	for _, f := range frames {
+		if err := w.writeString(f.Func); err != nil {
+			return err
+		}
+		if err := w.writeString(f.File); err != nil {
+			return err
+		}
+		if err := w.writeUvarint(f.Line); err != nil {
+			return err
+		}
+	}
+	if err := w.writeUvarint(allocs); err != nil {
+		return err
+	}
+	return w.writeUvarint(frees)
+}
+
+// WriteAllocSample ties a live object to the MemProf bucket that
+// recorded its allocation site.
+func (w *Writer) WriteAllocSample(addr, bucket uint64) error {
+	if err := w.writeTag(tagAllocSample); err != nil {
+		return err
+	}
+	if err := w.writeUvarint(addr); err != nil {
+		return err
+	}
+	return w.writeUvarint(bucket)
+}
@@ -0,0 +1,88 @@
+package writer_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/dullgiulio/hdump/parser"
+	"github.com/dullgiulio/hdump/writer"
+)
+
+type recorder struct {
+	parser.NopVisitor
+	params    *parser.Params
+	objects   []*parser.Object
+	types     []*parser.Type
+	goroutine *parser.Goroutine
+}
+
+func (r *recorder) OnParams(_ *parser.Context, p *parser.Params) error {
+	r.params = p
+	return nil
+}
+
+func (r *recorder) OnObject(_ *parser.Context, o *parser.Object) error {
+	r.objects = append(r.objects, o)
+	return nil
+}
+
+func (r *recorder) OnType(_ *parser.Context, t *parser.Type) error {
+	r.types = append(r.types, t)
+	return nil
+}
+
+func (r *recorder) OnGoroutine(_ *parser.Context, g *parser.Goroutine) error {
+	r.goroutine = g
+	return nil
+}
+
+// TestRoundTrip writes a small synthetic dump with the writer package and
+// checks that parsing it back with the parser package reproduces the
+// same records, byte for byte where applicable.
+func TestRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := writer.NewWriter(&buf)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.WriteParams(false, 8, 0x1000, 0x2000, "amd64", "", 4); err != nil {
+		t.Fatalf("WriteParams: %v", err)
+	}
+	if err := w.WriteType(0xc000010000, 16, "main.T", true); err != nil {
+		t.Fatalf("WriteType: %v", err)
+	}
+	if err := w.WriteObject(0xc000020000, []byte("hello"), []writer.Field{
+		{Kind: writer.FieldKindPtr, Ptr: 8},
+	}); err != nil {
+		t.Fatalf("WriteObject: %v", err)
+	}
+	started := time.Unix(0, 1234567890123)
+	if err := w.WriteGoroutine(0xc000030000, 0xc000040000, 1, 0, writer.StatusRunnable, true, false, started, "", 0, 0, 0, 0); err != nil {
+		t.Fatalf("WriteGoroutine: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec := &recorder{}
+	if err := parser.Parse(&buf, rec); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if rec.params == nil || rec.params.Arch != "amd64" || rec.params.PtrSize != 8 {
+		t.Fatalf("unexpected params: %+v", rec.params)
+	}
+	if len(rec.types) != 1 || rec.types[0].Name != "main.T" || rec.types[0].Size != 16 || !rec.types[0].IsPtr {
+		t.Fatalf("unexpected types: %+v", rec.types)
+	}
+	if len(rec.objects) != 1 || rec.objects[0].Addr != 0xc000020000 || string(rec.objects[0].Contents()) != "hello" {
+		t.Fatalf("unexpected objects: %+v", rec.objects)
+	}
+	if rec.goroutine == nil || rec.goroutine.ID != 1 || !rec.goroutine.IsSys || rec.goroutine.IsBackground {
+		t.Fatalf("unexpected goroutine: %+v", rec.goroutine)
+	}
+	if !rec.goroutine.Started.Equal(started) {
+		t.Fatalf("started time mismatch: got %v want %v", rec.goroutine.Started, started)
+	}
+}